@@ -2,173 +2,114 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
-	"math"
 	"os"
-	"os/exec"
-	"text/template"
+	"strings"
 
-	"github.com/davecgh/go-spew/spew"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/tmc/it2open/it2open"
 )
 
 var (
-	flagCols   = flag.Int("cols", 4, "number of columns")
-	flagNewTab = flag.Bool("tab", true, "if true, open a new tab")
-	flagDebug  = flag.Bool("debug", false, "if true, dump out applescript instead of running it")
-	flagDelay  = flag.Float64("delay", 0.25, "delay in seconds")
+	flagCols       = flag.Int("cols", 4, "number of columns (ignored if -layout or -layout-file is given)")
+	flagNewTab     = flag.Bool("tab", true, "if true, open a new tab")
+	flagDebug      = flag.Bool("debug", false, "if true, dump out the generated script instead of running it")
+	flagDelay      = flag.Float64("delay", 0.25, "delay in seconds (applescript backend only)")
+	flagFile       = flag.String("file", "", "read pane input from this file instead of stdin")
+	flagFormat     = flag.String("format", "", "input format: raw, yaml, or json (default: inferred from -file's extension, else raw)")
+	flagBackend    = flag.String("backend", "applescript", "backend to use: applescript, tmux, or python")
+	flagLayout     = flag.String("layout", "", `layout DSL describing a split tree, e.g. "h{ a:2 | v{ b | c } }"`)
+	flagLayoutFile = flag.String("layout-file", "", "read the layout DSL from this file instead of -layout")
+	flagSync       = flag.String("sync", "", "an additional command to send to every pane once its own command has run")
+	flagBroadcast  = flag.Bool("broadcast", false, "if true, turn on input broadcasting across every pane once commands have run")
 )
 
 func main() {
 	flag.Parse()
-	if err := run(*flagCols, *flagNewTab, *flagDelay, *flagDebug); err != nil {
+	if err := run(); err != nil {
 		fmt.Fprintf(os.Stderr, "it2open: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func run(cols int, newTab bool, delay float64, debug bool) error {
-	cmds, err := splitStdin()
+// run reads panes and a layout per the CLI flags, then hands them to
+// it2open.Open. It is intentionally thin: everything past parsing flags
+// and opening file handles lives in the it2open package, where it can be
+// driven programmatically without a stdin pipe or a flag.Parse call.
+func run() error {
+	panes, err := readPanes(*flagFile, *flagFormat)
 	if err != nil {
-		return errors.Wrap(err, "reading stdin")
-	}
-
-	rows := (len(cmds) + cols - 1) / cols
-
-	ctx := struct {
-		Cols   int
-		Rows   int
-		Delay  float64
-		Cmds   []string
-		Layout [][]string
-		NewTab bool
-	}{Cols: cols, Rows: rows,
-		Delay:  delay,
-		Cmds:   cmds,
-		Layout: distributeCommands(cmds, cols),
-		NewTab: newTab,
+		return errors.Wrap(err, "reading input")
 	}
-	spew.Dump(ctx.Cmds)
 
-	tmpl, err := template.New("applescript-template").
-		Funcs(funcMap).
-		Parse(appleScriptTemplate)
+	layout, err := loadLayout(*flagLayout, *flagLayoutFile)
 	if err != nil {
-		return err
+		return errors.Wrap(err, "parsing layout")
 	}
 
-	buf := new(bytes.Buffer)
-	if debug {
-		fmt.Printf("%+v\n", ctx)
-	}
-	if err := tmpl.Execute(buf, ctx); err != nil {
-		return err
-	}
-	if debug {
-		io.Copy(os.Stdout, buf)
-		return nil
-	}
-	return runAppleScript(buf)
+	return it2open.Open(context.Background(), it2open.Options{
+		Panes:     panes,
+		Layout:    layout,
+		Cols:      *flagCols,
+		NewTab:    *flagNewTab,
+		Backend:   *flagBackend,
+		Delay:     *flagDelay,
+		Debug:     *flagDebug,
+		Sync:      *flagSync,
+		Broadcast: *flagBroadcast,
+	})
 }
 
-// distributeCommands takes a list of commands and returns a 2D array of commands
-// that can be run in parallel in iTerm2.
-// the first layer in the array is the column, the second layer is the row.
-func distributeCommands(cmds []string, cols int) [][]string {
-	rows := (len(cmds) + cols - 1) / cols
-	r := make([][]string, cols)
-	for i := range r {
-		r[i] = make([]string, rows)
-	}
-	for i, cmd := range cmds {
-		r[i%cols][i/cols] = cmd
+// readPanes reads pane input from path, or from stdin if path is empty, and
+// parses it according to format. If format is empty it is inferred from
+// path's extension (.yaml/.yml or .json), falling back to "raw": one
+// command per line, with no other pane metadata.
+func readPanes(path, format string) ([]it2open.Pane, error) {
+	var r io.Reader
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "opening input file")
+		}
+		defer f.Close()
+		r = f
+	} else {
+		if terminal.IsTerminal(0) {
+			return nil, errors.New("expecting lines on stdin")
+		}
+		r = os.Stdin
 	}
-	return r
-}
 
-func splitStdin() ([]string, error) {
-	if terminal.IsTerminal(0) {
-		return nil, fmt.Errorf("expecting lines on stdin")
-	}
-	lines := []string{}
-	s := bufio.NewScanner(os.Stdin)
-	for s.Scan() {
-		lines = append(lines, s.Text())
+	if format == "" {
+		format = it2open.FormatFromExt(path)
 	}
-	return lines, s.Err()
-}
 
-func runAppleScript(script *bytes.Buffer) error {
-	tempFile, err := ioutil.TempFile("", "it2open")
+	data, err := ioutil.ReadAll(r)
 	if err != nil {
-		return errors.Wrap(err, "creating tempFile")
-	}
-	defer os.Remove(tempFile.Name()) // clean up
-
-	if _, err := io.Copy(tempFile, script); err != nil {
-		return errors.Wrap(err, "copy to tempFile")
+		return nil, errors.Wrap(err, "reading input")
 	}
-
-	if err := tempFile.Close(); err != nil {
-		log.Fatal(err)
-		return errors.Wrap(err, "closing")
-	}
-
-	cmd := exec.Command("osascript", tempFile.Name())
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	return errors.Wrap(cmd.Run(), "running osascript")
+	return it2open.ParsePanes(data, format)
 }
 
-// func map:
-var funcMap = template.FuncMap{
-	"sub":  func(a, b int) int { return a - b },
-	"mod":  func(a, b int) int { return a % b },
-	"mul":  func(a, b int) int { return a * b },
-	"add":  func(a, b int) int { return a + b },
-	"ceil": func(a float64) int { return int(math.Ceil(a)) },
-	"div":  func(a, b int) float64 { return float64(a) / float64(b) },
-	"until": func(n int) []int {
-		r := make([]int, n)
-		for i := range r {
-			r[i] = i
+// loadLayout reads and parses the layout DSL from layoutFile (if set) or
+// expr, returning a nil Layout when neither is given so callers can fall
+// back to the default grid.
+func loadLayout(expr, layoutFile string) (it2open.Layout, error) {
+	if layoutFile != "" {
+		data, err := ioutil.ReadFile(layoutFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading layout file")
 		}
-		return r
-	},
+		expr = string(data)
+	}
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+	return it2open.ParseLayout(expr)
 }
-
-const appleScriptTemplate = `
-tell application "iTerm2"
-	{{ if .NewTab }}tell current window to create tab with default profile{{ end }}
-
-	{{ range until .Cols }}
-		{{- if gt . 0 }}
-        tell current session of current tab of current window to split vertically with default profile
-		{{ end }}
-	{{ end }}
-	delay {{ $.Delay }}
-
-	{{- range $i, $col := .Layout }}
-		{{- range $j := until (sub (len $col) 1) }}
-			delay {{ $.Delay }}
-			tell current session of current tab of current window to split horizontally with default profile
-			tell application "System Events" to keystroke "]" using {command down}
-		{{- end }}
-		tell application "System Events" to keystroke "]" using {command down}
-	{{- end }}
-delay {{ $.Delay }}
-
-	{{- range $i, $cmd := .Cmds }}
-		tell current session of current tab of current window to write text "{{ $cmd }}"
-		tell application "System Events" to keystroke "]" using {command down}
-		delay {{ $.Delay }}
-	{{- end }}
-end tell
-`
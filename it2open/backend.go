@@ -0,0 +1,87 @@
+package it2open
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// SessionID identifies a pane within a backend's own session space (an
+// AppleScript session id, a tmux pane id, or a Python API session id).
+// Backends must support addressing a pane by the SessionID they handed
+// back from NewTab/Split, rather than relying on "whatever is current".
+type SessionID string
+
+// Direction is the split orientation passed to Backend.Split.
+type Direction int
+
+const (
+	// DirVertical splits a pane into left/right halves.
+	DirVertical Direction = iota
+	// DirHorizontal splits a pane into top/bottom halves.
+	DirHorizontal
+)
+
+// Backend drives iTerm2 through a particular transport. Callers create a
+// window/tab with NewTab, grow it out with Split, and push text into panes
+// with Send. Close flushes and releases any resources the backend held
+// (a buffered script, a subprocess, a connection).
+type Backend interface {
+	// NewTab creates a new tab when newTab is true, or reuses the current
+	// tab otherwise, and returns the SessionID of its initial pane.
+	NewTab(newTab bool) (SessionID, error)
+	// Split splits session in the given direction and returns the
+	// SessionID of the newly created pane.
+	Split(session SessionID, dir Direction) (SessionID, error)
+	// Send writes text into session, as if typed followed by return.
+	Send(session SessionID, text string) error
+	// Close flushes any buffered work and releases backend resources.
+	Close() error
+}
+
+// TitleSetter is implemented by backends that can set a pane's displayed
+// title.
+type TitleSetter interface {
+	SetTitle(session SessionID, title string) error
+}
+
+// ProfileSetter is implemented by backends that can switch a pane to a
+// named profile after creation.
+type ProfileSetter interface {
+	SetProfile(session SessionID, profile string) error
+}
+
+// Resizer is implemented by backends that can approximate layout size
+// ratios by setting a pane's character grid dimensions. A columns or rows
+// value of 0 means "leave that dimension alone".
+type Resizer interface {
+	Resize(session SessionID, columns, rows int) error
+}
+
+// Broadcaster is implemented by backends that can fan subsequent input
+// out to multiple panes at once. sessions is every pane Open generated;
+// backends that can only toggle broadcasting on or off for a whole
+// window or tab (rather than naming individual panes) may use only one of
+// them, or none.
+type Broadcaster interface {
+	Broadcast(sessions []SessionID) error
+}
+
+// newBackend constructs the Backend named by name ("applescript", "tmux",
+// or "python"). delay is only meaningful to the applescript backend, which
+// still paces itself with delays between splits. ctx bounds any subprocess
+// the backend shells out to. When debug is set, every backend writes its
+// generated script (or, for tmux, its tmux commands) to debugOutput instead
+// of running it.
+func newBackend(ctx context.Context, name string, delay float64, debug bool, debugOutput io.Writer) (Backend, error) {
+	switch name {
+	case "", "applescript":
+		return newAppleScriptBackend(ctx, delay, debug, debugOutput), nil
+	case "tmux":
+		return newTmuxBackend(ctx, debug, debugOutput), nil
+	case "python":
+		return newPythonBackend(ctx, debug, debugOutput), nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q", name)
+	}
+}
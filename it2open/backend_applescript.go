@@ -0,0 +1,142 @@
+package it2open
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// appleScriptBackend drives iTerm2 by buffering a single AppleScript and
+// running it all at once on Close. Each session it creates is captured
+// into a script variable holding that session's unique id, so later
+// Split/Send/SetTitle/SetProfile calls address it with `session id ...`
+// instead of the racier `current session`.
+type appleScriptBackend struct {
+	ctx    context.Context
+	delay  float64
+	debug  bool
+	output io.Writer
+	lines  []string
+	nextID int
+	vars   map[SessionID]string
+}
+
+func newAppleScriptBackend(ctx context.Context, delay float64, debug bool, output io.Writer) *appleScriptBackend {
+	return &appleScriptBackend{ctx: ctx, delay: delay, vars: map[SessionID]string{}, debug: debug, output: output}
+}
+
+func (b *appleScriptBackend) emit(format string, args ...interface{}) {
+	b.lines = append(b.lines, fmt.Sprintf(format, args...))
+}
+
+// captureCurrent records the current session's unique id into a fresh
+// script variable and returns the SessionID that refers to it.
+func (b *appleScriptBackend) captureCurrent() SessionID {
+	b.nextID++
+	v := fmt.Sprintf("sid%d", b.nextID)
+	b.emit("set %s to (unique id of current session of current tab of current window)", v)
+	sess := SessionID(v)
+	b.vars[sess] = v
+	return sess
+}
+
+func (b *appleScriptBackend) ref(session SessionID) string {
+	return fmt.Sprintf(`session id %s of current tab of current window`, b.vars[session])
+}
+
+func (b *appleScriptBackend) NewTab(newTab bool) (SessionID, error) {
+	if newTab {
+		b.emit(`tell current window to create tab with default profile`)
+	}
+	return b.captureCurrent(), nil
+}
+
+func (b *appleScriptBackend) Split(session SessionID, dir Direction) (SessionID, error) {
+	orientation := "vertically"
+	if dir == DirHorizontal {
+		orientation = "horizontally"
+	}
+	b.emit(`tell %s to split %s with default profile`, b.ref(session), orientation)
+	b.emit(`delay %v`, b.delay)
+	return b.captureCurrent(), nil
+}
+
+func (b *appleScriptBackend) Send(session SessionID, text string) error {
+	b.emit(`tell %s to write text "%s"`, b.ref(session), quoteAppleScript(text))
+	return nil
+}
+
+func (b *appleScriptBackend) SetTitle(session SessionID, title string) error {
+	b.emit(`tell %s to set name to "%s"`, b.ref(session), quoteAppleScript(title))
+	return nil
+}
+
+func (b *appleScriptBackend) SetProfile(session SessionID, profile string) error {
+	b.emit(`tell %s to set profile to "%s"`, b.ref(session), quoteAppleScript(profile))
+	return nil
+}
+
+// quoteAppleScript escapes s for interpolation inside an AppleScript
+// double-quoted string literal, so pane commands, titles, and profile
+// names containing a `"` or `\` don't break the generated script.
+func quoteAppleScript(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return s
+}
+
+// Broadcast toggles iTerm2's "Broadcast Input to All Panes in Current
+// Tab" via its Edit menu keyboard shortcut; iTerm2 has no AppleScript
+// verb for broadcast state, so this goes through System Events like the
+// menu shortcut a user would press by hand.
+func (b *appleScriptBackend) Broadcast(sessions []SessionID) error {
+	b.emit(`tell application "System Events" to keystroke "i" using {command down, option down}`)
+	return nil
+}
+
+func (b *appleScriptBackend) Resize(session SessionID, columns, rows int) error {
+	if columns > 0 {
+		b.emit(`tell %s to set columns to %d`, b.ref(session), columns)
+	}
+	if rows > 0 {
+		b.emit(`tell %s to set rows to %d`, b.ref(session), rows)
+	}
+	return nil
+}
+
+func (b *appleScriptBackend) Close() error {
+	script := fmt.Sprintf("tell application \"iTerm2\"\n%s\nend tell\n", strings.Join(b.lines, "\n"))
+	if b.debug {
+		fmt.Fprint(b.output, script)
+		return nil
+	}
+	return errors.Wrap(runAppleScript(b.ctx, bytes.NewBufferString(script)), "running applescript backend")
+}
+
+func runAppleScript(ctx context.Context, script *bytes.Buffer) error {
+	tempFile, err := ioutil.TempFile("", "it2open")
+	if err != nil {
+		return errors.Wrap(err, "creating tempFile")
+	}
+	defer os.Remove(tempFile.Name()) // clean up
+
+	if _, err := io.Copy(tempFile, script); err != nil {
+		return errors.Wrap(err, "copy to tempFile")
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return errors.Wrap(err, "closing")
+	}
+
+	cmd := exec.CommandContext(ctx, "osascript", tempFile.Name())
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	return errors.Wrap(cmd.Run(), "running osascript")
+}
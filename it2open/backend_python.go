@@ -0,0 +1,126 @@
+package it2open
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// pythonBackend drives iTerm2 over its local WebSocket API via the
+// `iterm2` Python package. Like appleScriptBackend it buffers a single
+// script (this time Python, using iterm2's async session objects directly
+// as variables) and runs it once on Close, so there's no polling or
+// fixed delay between operations.
+type pythonBackend struct {
+	ctx    context.Context
+	debug  bool
+	output io.Writer
+	lines  []string
+	nextID int
+}
+
+func newPythonBackend(ctx context.Context, debug bool, output io.Writer) *pythonBackend {
+	return &pythonBackend{ctx: ctx, debug: debug, output: output}
+}
+
+func (b *pythonBackend) emit(format string, args ...interface{}) {
+	b.lines = append(b.lines, "    "+fmt.Sprintf(format, args...))
+}
+
+func (b *pythonBackend) newVar() string {
+	b.nextID++
+	return fmt.Sprintf("session%d", b.nextID)
+}
+
+func (b *pythonBackend) newDomainVar() string {
+	b.nextID++
+	return fmt.Sprintf("domain%d", b.nextID)
+}
+
+func (b *pythonBackend) NewTab(newTab bool) (SessionID, error) {
+	v := b.newVar()
+	if newTab {
+		b.emit("tab = await app.current_terminal_window.async_create_tab()")
+		b.emit("%s = tab.current_session", v)
+	} else {
+		b.emit("%s = app.current_terminal_window.current_tab.current_session", v)
+	}
+	return SessionID(v), nil
+}
+
+func (b *pythonBackend) Split(session SessionID, dir Direction) (SessionID, error) {
+	vertical := "True"
+	if dir == DirHorizontal {
+		vertical = "False"
+	}
+	v := b.newVar()
+	b.emit("%s = await %s.async_split_pane(vertical=%s)", v, session, vertical)
+	return SessionID(v), nil
+}
+
+func (b *pythonBackend) Send(session SessionID, text string) error {
+	b.emit("await %s.async_send_text(%q + \"\\n\")", session, text)
+	return nil
+}
+
+func (b *pythonBackend) SetTitle(session SessionID, title string) error {
+	b.emit("await %s.async_set_name(%q)", session, title)
+	return nil
+}
+
+func (b *pythonBackend) SetProfile(session SessionID, profile string) error {
+	b.emit("await %s.async_set_profile_properties(iterm2.LocalWriteOnlyProfile().set_name(%q))", session, profile)
+	return nil
+}
+
+// Broadcast puts every session into a single iterm2.BroadcastDomain, which
+// is the Python API's equivalent of turning on "Broadcast Input to All
+// Panes" but scoped to exactly the sessions named, rather than a whole tab.
+// BroadcastDomain takes no constructor kwargs; sessions are added one at a
+// time via add_session.
+func (b *pythonBackend) Broadcast(sessions []SessionID) error {
+	domain := b.newDomainVar()
+	b.emit("%s = iterm2.BroadcastDomain()", domain)
+	for _, s := range sessions {
+		b.emit("%s.add_session(%s)", domain, s)
+	}
+	b.emit("await iterm2.async_set_broadcast_domains(connection, [%s])", domain)
+	return nil
+}
+
+func (b *pythonBackend) Close() error {
+	script := "import iterm2\n\n" +
+		"async def main(connection):\n" +
+		"    app = await iterm2.async_get_app(connection)\n" +
+		strings.Join(b.lines, "\n") + "\n\n" +
+		"iterm2.run_until_complete(main)\n"
+
+	if b.debug {
+		fmt.Fprint(b.output, script)
+		return nil
+	}
+
+	f, err := ioutil.TempFile("", "it2open-*.py")
+	if err != nil {
+		return errors.Wrap(err, "creating python script")
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(script); err != nil {
+		return errors.Wrap(err, "writing python script")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "closing python script")
+	}
+
+	cmd := exec.CommandContext(b.ctx, "python3", f.Name())
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+	return errors.Wrap(cmd.Run(), "running python backend")
+}
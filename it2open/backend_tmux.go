@@ -0,0 +1,102 @@
+package it2open
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// tmuxBackend drives iTerm2 through its tmux integration: it builds a
+// detached tmux session with plain tmux commands, addressing panes by
+// their tmux pane id (e.g. "%3"), then opens it in iTerm2 with
+// `tmux -CC attach`, which iTerm2 renders as native windows/tabs/splits
+// kept in sync with the tmux session. Because every pane is addressed by
+// id up front, there's no keystroke navigation and no delay between
+// operations.
+type tmuxBackend struct {
+	ctx     context.Context
+	session string
+	debug   bool
+	output  io.Writer
+	opened  bool
+}
+
+func newTmuxBackend(ctx context.Context, debug bool, output io.Writer) *tmuxBackend {
+	return &tmuxBackend{ctx: ctx, session: fmt.Sprintf("it2open-%d", os.Getpid()), debug: debug, output: output}
+}
+
+// tmux prints args as a "tmux ..." invocation and returns without running
+// it when b.debug is set, matching the dry-run contract the applescript
+// and python backends honor; otherwise it runs tmux for real and returns
+// its trimmed stdout.
+func (b *tmuxBackend) tmux(args ...string) (string, error) {
+	if b.debug {
+		fmt.Fprintln(b.output, "tmux", strings.Join(args, " "))
+		return "", nil
+	}
+	out, err := exec.CommandContext(b.ctx, "tmux", args...).Output()
+	return strings.TrimSpace(string(out)), errors.Wrap(err, "running tmux")
+}
+
+// NewTab always opens the tmux session in a new iTerm2 window; newTab is
+// ignored, and the current tab is never reused.
+func (b *tmuxBackend) NewTab(newTab bool) (SessionID, error) {
+	pane, err := b.tmux("new-session", "-d", "-s", b.session, "-P", "-F", "#{pane_id}")
+	if err != nil {
+		return "", err
+	}
+	if !b.debug {
+		cmd := exec.CommandContext(b.ctx, "osascript", "-e", fmt.Sprintf(
+			`tell application "iTerm2" to create window with default profile command "tmux -CC attach -t %s"`, b.session))
+		if err := cmd.Run(); err != nil {
+			return "", errors.Wrap(err, "opening tmux -CC window in iTerm2")
+		}
+	}
+	b.opened = true
+	return SessionID(pane), nil
+}
+
+func (b *tmuxBackend) Split(session SessionID, dir Direction) (SessionID, error) {
+	// tmux's -h places the new pane beside the old one (left/right) and -v
+	// below it (top/bottom).
+	flag := "-h"
+	if dir == DirHorizontal {
+		flag = "-v"
+	}
+	pane, err := b.tmux("split-window", flag, "-t", string(session), "-P", "-F", "#{pane_id}")
+	if err != nil {
+		return "", err
+	}
+	return SessionID(pane), nil
+}
+
+func (b *tmuxBackend) Send(session SessionID, text string) error {
+	_, err := b.tmux("send-keys", "-t", string(session), text, "Enter")
+	return err
+}
+
+func (b *tmuxBackend) SetTitle(session SessionID, title string) error {
+	_, err := b.tmux("select-pane", "-t", string(session), "-T", title)
+	return err
+}
+
+// Broadcast turns on tmux's synchronize-panes window option, which mirrors
+// keystrokes to every pane in the window containing sessions[0]. tmux has
+// no concept of selecting a subset of a window's panes to synchronize, so
+// the rest of sessions is unused.
+func (b *tmuxBackend) Broadcast(sessions []SessionID) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	_, err := b.tmux("set-window-option", "-t", string(sessions[0]), "synchronize-panes", "on")
+	return err
+}
+
+func (b *tmuxBackend) Close() error {
+	return nil
+}
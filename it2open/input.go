@@ -0,0 +1,57 @@
+package it2open
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// ParsePanes parses data as the given format ("raw", "yaml", or "json")
+// into a list of Panes. "raw" (the default for an unrecognized or empty
+// format) treats each line of data as a bare command with no other pane
+// metadata.
+func ParsePanes(data []byte, format string) ([]Pane, error) {
+	switch format {
+	case "yaml":
+		var panes []Pane
+		if err := yaml.Unmarshal(data, &panes); err != nil {
+			return nil, errors.Wrap(err, "parsing yaml input")
+		}
+		return panes, nil
+	case "json":
+		var panes []Pane
+		if err := json.Unmarshal(data, &panes); err != nil {
+			return nil, errors.Wrap(err, "parsing json input")
+		}
+		return panes, nil
+	default:
+		return parseRawLines(data), nil
+	}
+}
+
+// FormatFromExt infers a ParsePanes format from a file's extension,
+// returning "raw" when the extension is unrecognized or path is empty.
+func FormatFromExt(path string) string {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".json":
+		return "json"
+	default:
+		return "raw"
+	}
+}
+
+// parseRawLines treats each line of data as a bare command.
+func parseRawLines(data []byte) []Pane {
+	var panes []Pane
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		panes = append(panes, Pane{Cmd: s.Text()})
+	}
+	return panes
+}
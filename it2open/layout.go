@@ -0,0 +1,240 @@
+package it2open
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Layout is a node in a parsed layout tree: either a SplitNode dividing
+// its region between children, or a PaneNode leaf that a single pane is
+// assigned to.
+type Layout interface {
+	node()
+	ratio() int
+}
+
+// SplitNode divides its region among Children along Dir. Ratio is this
+// node's own weight relative to its siblings (see PaneNode.Ratio).
+type SplitNode struct {
+	Dir      Direction
+	Ratio    int
+	Children []Layout
+	Pos      Position
+}
+
+func (*SplitNode) node()        {}
+func (n *SplitNode) ratio() int { return n.Ratio }
+
+// PaneNode is a leaf assigned the next pane in left-to-right, depth-first
+// order. Cmd is the label it was written with in the layout source (e.g.
+// "a"); it's kept for reference only and is never run as a command. Ratio
+// is this node's weight relative to its siblings, from an optional `:N`
+// suffix (default 1).
+type PaneNode struct {
+	Cmd   string
+	Ratio int
+	Pos   Position
+}
+
+func (*PaneNode) node()        {}
+func (n *PaneNode) ratio() int { return n.Ratio }
+
+// Position is a 1-based line/column into layout source text, carried
+// through the AST; parse errors (ParseError) report it.
+type Position struct {
+	Line, Col int
+}
+
+// ParseError is returned by ParseLayout when the layout DSL is malformed.
+type ParseError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("layout:%d:%d: %s", e.Pos.Line, e.Pos.Col, e.Msg)
+}
+
+// ParseLayout parses a layout DSL expression such as
+// `h{ a:2 | v{ b | c } }` into a tree of SplitNode/PaneNode. "h"
+// arranges its children left to right, "v" top to bottom; an optional
+// `:N` ratio after any node weights it against its siblings (default 1).
+// Leaf identifiers are labels only: panes are assigned to leaves in
+// left-to-right, depth-first order, independent of the label text.
+func ParseLayout(src string) (Layout, error) {
+	p := newLayoutParser(src)
+	node, err := p.parseNode()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if _, ok := p.peek(); ok {
+		return nil, p.errorf(p.position(), "unexpected trailing input")
+	}
+	return node, nil
+}
+
+type layoutParser struct {
+	src  []rune
+	pos  int
+	line int
+	col  int
+}
+
+func newLayoutParser(src string) *layoutParser {
+	return &layoutParser{src: []rune(src), line: 1, col: 1}
+}
+
+func (p *layoutParser) peek() (rune, bool) {
+	if p.pos >= len(p.src) {
+		return 0, false
+	}
+	return p.src[p.pos], true
+}
+
+func (p *layoutParser) advance() (rune, bool) {
+	r, ok := p.peek()
+	if !ok {
+		return 0, false
+	}
+	p.pos++
+	if r == '\n' {
+		p.line++
+		p.col = 1
+	} else {
+		p.col++
+	}
+	return r, true
+}
+
+func (p *layoutParser) position() Position {
+	return Position{Line: p.line, Col: p.col}
+}
+
+func (p *layoutParser) skipSpace() {
+	for {
+		r, ok := p.peek()
+		if !ok || !unicode.IsSpace(r) {
+			return
+		}
+		p.advance()
+	}
+}
+
+func (p *layoutParser) errorf(pos Position, format string, args ...interface{}) error {
+	return &ParseError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *layoutParser) parseNode() (Layout, error) {
+	p.skipSpace()
+	pos := p.position()
+	ident, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if r, ok := p.peek(); ok && r == '{' {
+		dir, err := parseDir(ident, pos)
+		if err != nil {
+			return nil, err
+		}
+		p.advance() // consume '{'
+		children, err := p.parseChildren()
+		if err != nil {
+			return nil, err
+		}
+		ratio, err := p.parseRatioSuffix()
+		if err != nil {
+			return nil, err
+		}
+		return &SplitNode{Dir: dir, Ratio: ratio, Children: children, Pos: pos}, nil
+	}
+	ratio, err := p.parseRatioSuffix()
+	if err != nil {
+		return nil, err
+	}
+	return &PaneNode{Cmd: ident, Ratio: ratio, Pos: pos}, nil
+}
+
+func (p *layoutParser) parseChildren() ([]Layout, error) {
+	var children []Layout
+	for {
+		child, err := p.parseNode()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		p.skipSpace()
+		r, ok := p.peek()
+		if !ok {
+			return nil, p.errorf(p.position(), "unexpected end of input, expected '|' or '}'")
+		}
+		if r == '|' {
+			p.advance()
+			continue
+		}
+		if r == '}' {
+			p.advance()
+			return children, nil
+		}
+		return nil, p.errorf(p.position(), "unexpected %q, expected '|' or '}'", r)
+	}
+}
+
+// parseIdent reads a run of characters up to the next delimiter ('{',
+// '}', '|', ':') or whitespace.
+func (p *layoutParser) parseIdent() (string, error) {
+	start := p.pos
+	for {
+		r, ok := p.peek()
+		if !ok || strings.ContainsRune("{}|:", r) || unicode.IsSpace(r) {
+			break
+		}
+		p.advance()
+	}
+	if p.pos == start {
+		return "", p.errorf(p.position(), "expected an identifier")
+	}
+	return string(p.src[start:p.pos]), nil
+}
+
+// parseRatioSuffix consumes an optional ":N" and returns N, or 1 if no
+// suffix is present.
+func (p *layoutParser) parseRatioSuffix() (int, error) {
+	p.skipSpace()
+	r, ok := p.peek()
+	if !ok || r != ':' {
+		return 1, nil
+	}
+	pos := p.position()
+	p.advance()
+	start := p.pos
+	for {
+		r, ok := p.peek()
+		if !ok || !unicode.IsDigit(r) {
+			break
+		}
+		p.advance()
+	}
+	if p.pos == start {
+		return 0, p.errorf(pos, "expected a number after ':'")
+	}
+	n, err := strconv.Atoi(string(p.src[start:p.pos]))
+	if err != nil {
+		return 0, p.errorf(pos, "invalid ratio: %v", err)
+	}
+	return n, nil
+}
+
+func parseDir(ident string, pos Position) (Direction, error) {
+	switch ident {
+	case "h":
+		return DirVertical, nil // "h" arranges children left to right
+	case "v":
+		return DirHorizontal, nil // "v" stacks children top to bottom
+	default:
+		return 0, &ParseError{Pos: pos, Msg: fmt.Sprintf("unknown split kind %q, want \"h\" or \"v\"", ident)}
+	}
+}
@@ -0,0 +1,283 @@
+package it2open
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Options configures a call to Open: which panes to create, how to lay
+// them out, and which Backend to drive them through.
+type Options struct {
+	// Panes are the commands (and per-pane metadata) to open, assigned to
+	// sessions in left-to-right, depth-first order.
+	Panes []Pane
+	// Layout, if non-nil, is a parsed split tree to lay Panes out into
+	// (see ParseLayout). If nil, Panes are arranged into a Cols x rows
+	// grid instead.
+	Layout Layout
+	// Cols is the grid width used when Layout is nil. It must be positive
+	// in that case; Open returns an error otherwise.
+	Cols int
+	// NewTab opens a new tab before laying out panes, rather than reusing
+	// the current one.
+	NewTab bool
+	// Backend names the transport to drive iTerm2 with: "applescript"
+	// (the default), "tmux", or "python".
+	Backend string
+	// Delay is the pause, in seconds, the applescript backend takes
+	// between splits.
+	Delay float64
+	// Debug, if true, writes the generated script to Output instead of
+	// running it.
+	Debug bool
+	// Output is where a Debug run writes the generated script. Defaults
+	// to os.Stdout if nil; set it to capture the script instead (e.g. for
+	// a golden-file test) without it reaching the real stdout.
+	Output io.Writer
+	// Sync, if non-empty, is sent as an additional command to every
+	// generated pane once their per-pane commands have been sent (e.g. a
+	// `tail -f` or Ctrl-C fanned out across hosts).
+	Sync string
+	// Broadcast, if true, turns on input broadcasting across every
+	// generated pane once their per-pane commands have been sent, on
+	// backends that support it (see Broadcaster).
+	Broadcast bool
+}
+
+// Open lays out opts.Panes into new iTerm2 panes according to opts.Layout
+// (or a Cols x rows grid, if Layout is nil) and sends each pane's command.
+// ctx bounds any subprocess the chosen backend shells out to.
+func Open(ctx context.Context, opts Options) error {
+	output := opts.Output
+	if output == nil {
+		output = os.Stdout
+	}
+	b, err := newBackend(ctx, opts.Backend, opts.Delay, opts.Debug, output)
+	if err != nil {
+		return errors.Wrap(err, "selecting backend")
+	}
+
+	sessions, err := openPanes(b, opts.Panes, opts.Cols, opts.NewTab, opts.Layout)
+	if err != nil {
+		return errors.Wrapf(err, "opening panes with %s backend", opts.Backend)
+	}
+
+	if opts.Sync != "" {
+		for _, session := range sessions {
+			if err := b.Send(session, opts.Sync); err != nil {
+				return errors.Wrap(err, "sending sync command")
+			}
+		}
+	}
+	if opts.Broadcast {
+		if br, ok := b.(Broadcaster); ok {
+			if err := br.Broadcast(sessions); err != nil {
+				return errors.Wrap(err, "enabling broadcast")
+			}
+		}
+	}
+	return b.Close()
+}
+
+// openPanes lays panes out via layout if given, or else a cols x rows
+// grid, then sends each pane's setup (profile, title, working directory,
+// environment) and command to its session. It returns the distinct
+// sessions that actually received a pane, in left-to-right order — a grid
+// wider than len(panes) leaves trailing cells empty, and those are not
+// included.
+func openPanes(b Backend, panes []Pane, cols int, newTab bool, layout Layout) ([]SessionID, error) {
+	if len(panes) == 0 {
+		return nil, nil
+	}
+	if layout == nil && cols <= 0 {
+		return nil, errors.Errorf("cols must be positive, got %d", cols)
+	}
+
+	root, err := b.NewTab(newTab)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating tab")
+	}
+
+	var sessionFor func(i int) SessionID
+	if layout != nil {
+		leafSessions, err := applyLayout(b, root, layout)
+		if err != nil {
+			return nil, errors.Wrap(err, "applying layout")
+		}
+		if len(leafSessions) == 0 {
+			return nil, errors.New("layout has no panes")
+		}
+		sessionFor = func(i int) SessionID { return leafSessions[i%len(leafSessions)] }
+	} else {
+		rows := (len(panes) + cols - 1) / cols
+		grid, err := buildGrid(b, root, cols, rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "splitting grid")
+		}
+		sessionFor = func(i int) SessionID { return grid[i%cols][i/cols] }
+	}
+
+	sessions := make([]SessionID, len(panes))
+	for i, pane := range panes {
+		sessions[i] = sessionFor(i)
+		if err := sendPane(b, sessions[i], pane); err != nil {
+			return nil, errors.Wrapf(err, "sending pane %d", i)
+		}
+	}
+	return dedupeSessions(sessions), nil
+}
+
+// dedupeSessions returns sessions with later duplicates removed, keeping
+// first-seen order. Panes can share a session when a layout has fewer
+// leaves than panes (sessionFor wraps via modulo); sync/broadcast
+// should touch each such session only once.
+func dedupeSessions(sessions []SessionID) []SessionID {
+	seen := make(map[SessionID]bool, len(sessions))
+	out := sessions[:0:0]
+	for _, s := range sessions {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// buildGrid splits root into a cols x rows grid of sessions: cols-1
+// vertical splits build out the columns, then each column is split
+// horizontally rows-1 times. Every session is addressed by the SessionID
+// its creating Split/NewTab call returned, never by "whatever is current".
+func buildGrid(b Backend, root SessionID, cols, rows int) ([][]SessionID, error) {
+	colRoots, err := splitSiblings(b, root, DirVertical, cols)
+	if err != nil {
+		return nil, err
+	}
+
+	grid := make([][]SessionID, cols)
+	for i, colRoot := range colRoots {
+		grid[i], err = splitSiblings(b, colRoot, DirHorizontal, rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return grid, nil
+}
+
+// splitSiblings splits root along dir, then splits each newly created pane
+// in turn, n-1 splits in all, returning the n resulting sessions (root
+// itself plus one per split) in order.
+func splitSiblings(b Backend, root SessionID, dir Direction, n int) ([]SessionID, error) {
+	ids := make([]SessionID, n)
+	ids[0] = root
+	cur := root
+	for i := 1; i < n; i++ {
+		next, err := b.Split(cur, dir)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = next
+		cur = next
+	}
+	return ids, nil
+}
+
+// applyLayout walks a parsed layout tree, emitting Split (and, where the
+// backend supports it, Resize) calls via b, and returns its PaneNode
+// leaves' sessions in left-to-right, depth-first order.
+func applyLayout(b Backend, root SessionID, tree Layout) ([]SessionID, error) {
+	split, ok := tree.(*SplitNode)
+	if !ok {
+		return []SessionID{root}, nil
+	}
+
+	ids, err := splitSiblings(b, root, split.Dir, len(split.Children))
+	if err != nil {
+		return nil, err
+	}
+	resizeChildren(b, ids, split)
+
+	var leafSessions []SessionID
+	for i, child := range split.Children {
+		sub, err := applyLayout(b, ids[i], child)
+		if err != nil {
+			return nil, err
+		}
+		leafSessions = append(leafSessions, sub...)
+	}
+	return leafSessions, nil
+}
+
+// Nominal terminal dimensions layout ratios are resolved against, since
+// iTerm2 sizes panes in character cells rather than fractions.
+const (
+	nominalColumns = 160
+	nominalRows    = 50
+)
+
+// resizeChildren best-effort applies a split's children's relative
+// ratios via Resizer, if the backend supports it and the ratios aren't
+// all equal (the common case, where iTerm2's own even split suffices).
+func resizeChildren(b Backend, ids []SessionID, split *SplitNode) {
+	rs, ok := b.(Resizer)
+	if !ok {
+		return
+	}
+	sum := 0
+	uniform := true
+	for _, c := range split.Children {
+		sum += c.ratio()
+		uniform = uniform && c.ratio() == split.Children[0].ratio()
+	}
+	if uniform {
+		return
+	}
+	for i, id := range ids {
+		share := split.Children[i].ratio()
+		if split.Dir == DirVertical {
+			rs.Resize(id, share*nominalColumns/sum, 0)
+		} else {
+			rs.Resize(id, 0, share*nominalRows/sum)
+		}
+	}
+}
+
+// sendPane applies a pane's profile, title, working directory, and
+// environment (where the backend supports them) before sending its
+// command.
+func sendPane(b Backend, session SessionID, pane Pane) error {
+	if pane.Profile != "" {
+		if ps, ok := b.(ProfileSetter); ok {
+			if err := ps.SetProfile(session, pane.Profile); err != nil {
+				return err
+			}
+		}
+	}
+	if pane.Title != "" {
+		if ts, ok := b.(TitleSetter); ok {
+			if err := ts.SetTitle(session, pane.Title); err != nil {
+				return err
+			}
+		}
+	}
+	if pane.Dir != "" {
+		if err := b.Send(session, "cd "+pane.Dir); err != nil {
+			return err
+		}
+	}
+	keys := make([]string, 0, len(pane.Env))
+	for k := range pane.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := b.Send(session, fmt.Sprintf("export %s=%s", k, pane.Env[k])); err != nil {
+			return err
+		}
+	}
+	return b.Send(session, pane.Command())
+}
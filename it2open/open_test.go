@@ -0,0 +1,44 @@
+package it2open
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "overwrite golden files with the current output")
+
+func TestOpenAppleScriptGolden(t *testing.T) {
+	var buf bytes.Buffer
+	opts := Options{
+		Panes: []Pane{
+			{Cmd: "echo one"},
+			{Cmd: "echo two", Dir: "/tmp", Title: "two"},
+		},
+		Cols:    2,
+		NewTab:  true,
+		Backend: "applescript",
+		Delay:   0.1,
+		Debug:   true,
+		Output:  &buf,
+	}
+	if err := Open(context.Background(), opts); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	const golden = "testdata/applescript_basic.golden"
+	if *update {
+		if err := ioutil.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(want) {
+		t.Errorf("generated script differs from golden file (run with -update to refresh):\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
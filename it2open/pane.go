@@ -0,0 +1,26 @@
+// Package it2open lays out commands into new iTerm2 panes: parsing pane
+// input and layout descriptions, computing a split tree or grid, and
+// driving iTerm2 through a pluggable Backend. The it2open command is a
+// thin CLI wrapper around this package's Open function.
+package it2open
+
+// Pane describes a single pane to open: the command to run plus optional
+// metadata describing its iTerm2 profile, working directory, title, and
+// environment.
+type Pane struct {
+	Cmd     string            `json:"cmd" yaml:"cmd"`
+	Profile string            `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Dir     string            `json:"dir,omitempty" yaml:"dir,omitempty"`
+	Title   string            `json:"title,omitempty" yaml:"title,omitempty"`
+	Env     map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	Hold    bool              `json:"hold,omitempty" yaml:"hold,omitempty"`
+}
+
+// Command returns the text to write into the pane. If Hold is set, a shell
+// is execed after the command so the pane doesn't close when it exits.
+func (p Pane) Command() string {
+	if p.Hold {
+		return p.Cmd + "; exec $SHELL -l"
+	}
+	return p.Cmd
+}